@@ -16,16 +16,51 @@ limitations under the License.
 package cmd
 
 import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
 	"github.com/spf13/cobra"
+
 	"github.com/waynr/image-generator/pkg/image"
 )
 
+// builder names accepted by the --builder flag.
+const (
+	builderDockerDaemon = "docker-daemon"
+	builderGgcr         = "ggcr"
+	builderRegistry     = "registry"
+)
+
+// sink names accepted by the --output flag.
+const (
+	outputDaemon        = "daemon"
+	outputRegistry      = "registry"
+	outputOCILayout     = "oci-layout"
+	outputDockerArchive = "docker-archive"
+)
+
 // buildCmd represents the build command
 var (
-	layerSize  uint
-	layerCount uint
-	seed       = int64(4848484)
-	tags       = []string{
+	layerSize     uint
+	layerCount    uint
+	count         uint = 1
+	seed               = int64(4848484)
+	builderName        = builderDockerDaemon
+	outputName         = outputDaemon
+	push          bool
+	insecure      bool
+	platformFlag  = "linux/amd64"
+	platformsFlag []string
+	layoutPath    = "./oci-layout"
+	archivePath   = "./images.tar"
+
+	layerEntropy         = string(image.EntropyHigh)
+	targetCompressedSize uint
+	dedupRatio           float64
+
+	tags = []string{
 		"registry.digitalocean.com/meow/rando",
 	}
 	buildCmd = &cobra.Command{
@@ -37,24 +72,208 @@ and usage of using your command. For example:
 Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
-		RunE: func(cmd *cobra.Command, args []string) error {
-			randomImageFactory := image.RandomImageFactory{
-				LayerSizeKB: layerSize,
-				LayerCount:  layerCount,
-				Seed:        seed,
-				Tags:        []string{"registry.digitalocean.com/meow/rando"},
+		RunE: runGenerateImage,
+	}
+)
+
+// runGenerateImage generates one or more random images and writes them to
+// the selected output sink. It backs both buildCmd and pushCmd.
+func runGenerateImage(cmd *cobra.Command, args []string) error {
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	sinkName := outputName
+	if push {
+		sinkName = outputRegistry
+	}
+
+	platform, err := image.ParsePlatform(platformFlag)
+	if err != nil {
+		return err
+	}
+
+	contentProfile, err := newContentProfile()
+	if err != nil {
+		return err
+	}
+
+	// --platforms takes precedence over the single-image/--count flows below:
+	// it builds one image per platform and combines them into a single
+	// v1.ImageIndex, which requires a sink that understands manifest lists.
+	if len(platformsFlag) > 0 {
+		platforms, err := image.ParsePlatforms(platformsFlag)
+		if err != nil {
+			return err
+		}
+
+		indexSink, err := newIndexOutput(sinkName, logger)
+		if err != nil {
+			return err
+		}
+
+		randomImageFactory := image.NewRandomImageFactory(seed, image.WithLogger(logger), image.WithContentProfile(contentProfile))
+
+		report, err := randomImageFactory.GenerateIndex(platforms, layerSize, layerCount, tags, indexSink)
+		if err != nil {
+			return err
+		}
+
+		return printDigestReports(report)
+	}
+
+	// daemon output preserves the original single-image Builder flow
+	// (docker-daemon's `docker build`, or ggcr loading into the daemon),
+	// looping once per --count.
+	if sinkName == outputDaemon {
+		builder, err := newBuilder(builderName, logger)
+		if err != nil {
+			return err
+		}
+
+		// Reuse one factory across the loop: each call consumes further bytes
+		// from the same random source and writes into its own image-<i>
+		// subdirectory, so count distinct images come out the other end
+		// instead of the same cached file pool being reused for all of them.
+		randomImageFactory := image.NewRandomImageFactory(
+			seed,
+			image.WithLogger(logger),
+			image.WithBuilder(builder),
+			image.WithContentProfile(contentProfile),
+		)
+
+		// Only builders that assemble a v1.Image in-process (ggcr, registry)
+		// return a usable report; --builder docker-daemon hands the build
+		// off to `docker build` with no image to report on, so reports stays
+		// empty and nothing is printed.
+		reports := make([]image.DigestReport, 0, count)
+		for i := uint(0); i < count; i++ {
+			report, err := randomImageFactory.GenerateImage(i, count, layerSize, layerCount, image.IndexTags(tags, i, count))
+			if err != nil {
+				return err
+			}
+			if report.Digest != "" {
+				reports = append(reports, report)
 			}
+		}
 
-			return randomImageFactory.GenerateImage()
-		},
+		if len(reports) == 0 {
+			return nil
+		}
+		return printDigestReports(reports...)
 	}
-)
+
+	sink, err := newOutput(sinkName, logger)
+	if err != nil {
+		return err
+	}
+
+	randomImageFactory := image.NewRandomImageFactory(seed, image.WithLogger(logger), image.WithContentProfile(contentProfile))
+
+	reports, err := randomImageFactory.GenerateImages(count, layerSize, layerCount, tags, platform, sink)
+	if err != nil {
+		return err
+	}
+
+	return printDigestReports(reports...)
+}
+
+// printDigestReports writes reports to stdout as a JSON array, so external
+// tooling can record what was generated and compare it against a later
+// verify run.
+func printDigestReports(reports ...image.DigestReport) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(reports)
+}
+
+// newBuilder constructs the image.Builder named by builderName.
+func newBuilder(builderName string, logger *log.Logger) (image.Builder, error) {
+	switch builderName {
+	case builderDockerDaemon:
+		return image.NewDockerDaemonBuilder(logger)
+	case builderGgcr:
+		platform, err := image.ParsePlatform(platformFlag)
+		if err != nil {
+			return nil, err
+		}
+		return image.NewGgcrBuilder(logger, image.WithPlatform(platform)), nil
+	case builderRegistry:
+		platform, err := image.ParsePlatform(platformFlag)
+		if err != nil {
+			return nil, err
+		}
+		return image.NewRemoteBuilder(
+			logger,
+			image.WithInsecure(insecure),
+			image.WithRemotePlatform(platform),
+		), nil
+	default:
+		return nil, fmt.Errorf("unknown builder %q, expected %q, %q, or %q", builderName, builderDockerDaemon, builderGgcr, builderRegistry)
+	}
+}
+
+// newOutput constructs the image.Output sink named by outputName.
+func newOutput(outputName string, logger *log.Logger) (image.Output, error) {
+	switch outputName {
+	case outputRegistry:
+		return image.NewRegistryOutput(logger, insecure), nil
+	case outputOCILayout:
+		return image.NewLayoutOutput(layoutPath), nil
+	case outputDockerArchive:
+		return image.NewArchiveOutput(archivePath), nil
+	default:
+		return nil, fmt.Errorf("unknown output %q, expected %q, %q, %q, or %q", outputName, outputDaemon, outputRegistry, outputOCILayout, outputDockerArchive)
+	}
+}
+
+// newContentProfile builds the image.LayerContentProfile described by the
+// --layer-entropy, --target-compressed-size, and --dedup-ratio flags.
+func newContentProfile() (image.LayerContentProfile, error) {
+	switch image.EntropyMode(layerEntropy) {
+	case image.EntropyHigh, image.EntropyLow, image.EntropyMixed:
+	default:
+		return image.LayerContentProfile{}, fmt.Errorf("unknown layer entropy %q, expected %q, %q, or %q", layerEntropy, image.EntropyHigh, image.EntropyLow, image.EntropyMixed)
+	}
+
+	return image.LayerContentProfile{
+		Entropy:              image.EntropyMode(layerEntropy),
+		TargetCompressedSize: targetCompressedSize,
+		DedupRatio:           dedupRatio,
+	}, nil
+}
+
+// newIndexOutput constructs the image.IndexOutput sink named by outputName.
+// Unlike newOutput, daemon and docker-archive are rejected: neither the
+// docker daemon load path nor the classic docker-archive tarball format has
+// a notion of a multi-platform manifest list, so --platforms requires
+// --output registry or --output oci-layout.
+func newIndexOutput(outputName string, logger *log.Logger) (image.IndexOutput, error) {
+	switch outputName {
+	case outputRegistry:
+		return image.NewRegistryOutput(logger, insecure), nil
+	case outputOCILayout:
+		return image.NewLayoutOutput(layoutPath), nil
+	default:
+		return nil, fmt.Errorf("--platforms requires --output %q or --output %q, got %q", outputRegistry, outputOCILayout, outputName)
+	}
+}
 
 func init() {
 	rootCmd.AddCommand(buildCmd)
 
 	buildCmd.Flags().Int64VarP(&seed, "seed", "s", seed, "seed used to generate random layer contents")
 	buildCmd.Flags().StringSliceVarP(&tags, "tags", "t", tags, "tags for generated image")
+	buildCmd.Flags().StringVarP(&builderName, "builder", "", builderName, "image builder to use with --output daemon: docker-daemon, ggcr, or registry")
+	buildCmd.Flags().StringVarP(&outputName, "output", "", outputName, "output sink for generated images: daemon, registry, oci-layout, or docker-archive")
+	buildCmd.Flags().UintVarP(&count, "count", "", count, "number of distinct images to generate in one invocation")
+	buildCmd.Flags().BoolVarP(&push, "push", "", false, "push straight to a remote registry (shorthand for --output registry)")
+	buildCmd.Flags().BoolVarP(&insecure, "insecure", "", false, "allow pushing to registries without verifiable TLS")
+	buildCmd.Flags().StringVarP(&platformFlag, "platform", "", platformFlag, "target platform for generated images, as os/arch[/variant]")
+	buildCmd.Flags().StringSliceVarP(&platformsFlag, "platforms", "", platformsFlag, "build a multi-arch image index for these comma-separated platforms (os/arch[/variant]); requires --output registry or oci-layout")
+	buildCmd.Flags().StringVarP(&layoutPath, "layout-path", "", layoutPath, "directory to write the OCI image layout to (used with --output oci-layout)")
+	buildCmd.Flags().StringVarP(&archivePath, "archive-path", "", archivePath, "path to write the docker-archive tarball to (used with --output docker-archive)")
+	buildCmd.Flags().StringVarP(&layerEntropy, "layer-entropy", "", layerEntropy, "layer content compressibility: high, low, or mixed")
+	buildCmd.Flags().UintVarP(&targetCompressedSize, "target-compressed-size", "", targetCompressedSize, "generate layer content whose gzip-compressed size approximates this many bytes (overrides --layer-entropy)")
+	buildCmd.Flags().Float64VarP(&dedupRatio, "dedup-ratio", "", dedupRatio, "fraction (0-1) of generated layers that reuse an earlier layer's digest")
 
 	buildCmd.Flags().UintVarP(&layerCount, "layer-count", "", layerCount, "image layer count")
 	buildCmd.MarkFlagRequired("layer-count")
@@ -0,0 +1,53 @@
+/*
+Copyright © 2020 Wayne Warren
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// pushCmd is a convenience alias for `build --push`: it generates random
+// images and publishes them straight to a remote registry, without ever
+// loading them into a local docker daemon.
+var pushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "Generate random images and push them straight to a remote registry",
+	Long: `push generates random images the same way build does, but always
+publishes them directly to a remote registry via go-containerregistry
+instead of a local docker daemon. This makes it useful for benchmarking
+registries with large numbers of images.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		push = true
+		return runGenerateImage(cmd, args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pushCmd)
+
+	pushCmd.Flags().Int64VarP(&seed, "seed", "s", seed, "seed used to generate random layer contents")
+	pushCmd.Flags().StringSliceVarP(&tags, "tags", "t", tags, "tags for generated image")
+	pushCmd.Flags().BoolVarP(&insecure, "insecure", "", false, "allow pushing to registries without verifiable TLS")
+	pushCmd.Flags().StringVarP(&platformFlag, "platform", "", platformFlag, "target platform for pushed images, as os/arch[/variant]")
+	pushCmd.Flags().StringVarP(&layerEntropy, "layer-entropy", "", layerEntropy, "layer content compressibility: high, low, or mixed")
+	pushCmd.Flags().UintVarP(&targetCompressedSize, "target-compressed-size", "", targetCompressedSize, "generate layer content whose gzip-compressed size approximates this many bytes (overrides --layer-entropy)")
+	pushCmd.Flags().Float64VarP(&dedupRatio, "dedup-ratio", "", dedupRatio, "fraction (0-1) of generated layers that reuse an earlier layer's digest")
+
+	pushCmd.Flags().UintVarP(&layerCount, "layer-count", "", layerCount, "image layer count")
+	pushCmd.MarkFlagRequired("layer-count")
+	pushCmd.Flags().UintVarP(&layerSize, "layer-size", "", layerSize, "image layer size in KB")
+	pushCmd.MarkFlagRequired("layer-size")
+}
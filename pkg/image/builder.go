@@ -0,0 +1,23 @@
+package image
+
+import (
+	"context"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Builder turns the files generated by a RandomImageFactory into a published
+// image. Implementations differ in how they get from files on disk to a
+// finished image: DockerDaemonBuilder shells out to a running docker daemon
+// the way `docker build` does, while GgcrBuilder assembles the image
+// in-process and never requires a daemon at all.
+type Builder interface {
+	// Build constructs an image tagged with tags from files and
+	// dockerfilePath. dockerfilePath is only meaningful to builders that
+	// interpret a Dockerfile; others may ignore it. It returns the
+	// resulting v1.Image when the builder assembled one in-process, so
+	// callers can report its digest; DockerDaemonBuilder hands the build
+	// off to `docker build` with no image handle of its own and returns
+	// nil.
+	Build(ctx context.Context, files []string, dockerfilePath string, tags []string) (v1.Image, error)
+}
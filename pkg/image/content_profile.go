@@ -0,0 +1,74 @@
+package image
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+)
+
+// EntropyMode selects how LayerContentProfile fills generated file content.
+type EntropyMode string
+
+const (
+	// EntropyHigh fills content with uniformly random bytes across the full
+	// byte range, which gzip cannot meaningfully compress.
+	EntropyHigh EntropyMode = "high"
+	// EntropyLow tiles a short repeating pattern, which gzip compresses to a
+	// small fraction of its uncompressed size.
+	EntropyLow EntropyMode = "low"
+	// EntropyMixed pads a random prefix with a zeroed tail, sized so the
+	// gzip-compressed output lands near TargetCompressedSize, or near half
+	// the uncompressed size when TargetCompressedSize is zero.
+	EntropyMixed EntropyMode = "mixed"
+)
+
+// compressedSizeTolerance bounds how close the iterative target-compressed-
+// size search must land to TargetCompressedSize before it stops searching.
+const compressedSizeTolerance = 256 // bytes
+
+// lowEntropyPattern is tiled to fill EntropyLow content.
+var lowEntropyPattern = []byte("0")
+
+// LayerContentProfile controls the compressibility and size characteristics
+// of generated layer content, so images can exercise registry storage and
+// bandwidth behavior beyond plain high-entropy fill.
+type LayerContentProfile struct {
+	// Entropy selects the fill strategy. Defaults to EntropyHigh.
+	Entropy EntropyMode
+	// TargetCompressedSize, if non-zero, overrides Entropy: content is
+	// generated as a random prefix padded with a zeroed tail, iterating
+	// until its gzip-compressed size lands within compressedSizeTolerance
+	// of this many bytes.
+	TargetCompressedSize uint
+	// DedupRatio is the fraction, in [0, 1], of generated files in a layer
+	// pool that reuse a previously generated file's path instead of unique
+	// content, so the resulting layers share a digest with an earlier one
+	// and can exercise registry cross-repo mounting.
+	DedupRatio float64
+}
+
+// DefaultLayerContentProfile is pure high-entropy fill, no target size, no
+// dedup.
+var DefaultLayerContentProfile = LayerContentProfile{Entropy: EntropyHigh}
+
+// gzipLen returns the gzip-compressed size of b.
+func gzipLen(b []byte) (int, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		return 0, fmt.Errorf("failed to gzip content: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close gzip writer: %w", err)
+	}
+	return buf.Len(), nil
+}
+
+// tileBytes fills a slice of n bytes by repeating pattern.
+func tileBytes(pattern []byte, n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = pattern[i%len(pattern)]
+	}
+	return b
+}
@@ -0,0 +1,26 @@
+package image
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// sourceDateEpoch returns the build timestamp to embed in generated tar
+// headers and image config, honoring the SOURCE_DATE_EPOCH convention
+// (https://reproducible-builds.org/specs/source-date-epoch/) so that
+// generation is bit-for-bit reproducible given the same seed and inputs. If
+// SOURCE_DATE_EPOCH is unset or invalid, it falls back to the Unix epoch.
+func sourceDateEpoch() time.Time {
+	v := os.Getenv("SOURCE_DATE_EPOCH")
+	if v == "" {
+		return time.Unix(0, 0).UTC()
+	}
+
+	secs, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Unix(0, 0).UTC()
+	}
+
+	return time.Unix(secs, 0).UTC()
+}
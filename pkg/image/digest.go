@@ -0,0 +1,63 @@
+package image
+
+import (
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// DigestReport is the machine-parsable record build and push print to
+// stdout after generating an image or index, so CI and registry tests can
+// record and compare what was generated without re-deriving it.
+type DigestReport struct {
+	Tags      []string `json:"tags,omitempty"`
+	Digest    string   `json:"digest"`
+	Layers    []string `json:"layers,omitempty"`
+	Manifests []string `json:"manifests,omitempty"`
+}
+
+// NewDigestReport computes img's digest and the digest of each of its
+// layers.
+func NewDigestReport(tags []string, img v1.Image) (DigestReport, error) {
+	d, err := img.Digest()
+	if err != nil {
+		return DigestReport{}, fmt.Errorf("failed to compute image digest: %w", err)
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return DigestReport{}, fmt.Errorf("failed to list image layers: %w", err)
+	}
+
+	layerDigests := make([]string, 0, len(layers))
+	for _, l := range layers {
+		ld, err := l.Digest()
+		if err != nil {
+			return DigestReport{}, fmt.Errorf("failed to compute layer digest: %w", err)
+		}
+		layerDigests = append(layerDigests, ld.String())
+	}
+
+	return DigestReport{Tags: tags, Digest: d.String(), Layers: layerDigests}, nil
+}
+
+// NewIndexDigestReport computes idx's digest and the digest of each image
+// manifest it references.
+func NewIndexDigestReport(tags []string, idx v1.ImageIndex) (DigestReport, error) {
+	d, err := idx.Digest()
+	if err != nil {
+		return DigestReport{}, fmt.Errorf("failed to compute index digest: %w", err)
+	}
+
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return DigestReport{}, fmt.Errorf("failed to read index manifest: %w", err)
+	}
+
+	manifests := make([]string, 0, len(im.Manifests))
+	for _, m := range im.Manifests {
+		manifests = append(manifests, m.Digest.String())
+	}
+
+	return DigestReport{Tags: tags, Digest: d.String(), Manifests: manifests}, nil
+}
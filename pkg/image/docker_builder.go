@@ -0,0 +1,113 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// DockerDaemonBuilder builds images the way `docker build` does: it ships a
+// tar build context containing the generated files and a Dockerfile to a
+// running docker daemon.
+type DockerDaemonBuilder struct {
+	client *client.Client
+	logger *log.Logger
+}
+
+// NewDockerDaemonBuilder constructs a DockerDaemonBuilder using the docker
+// client configuration found in the environment (DOCKER_HOST and friends).
+func NewDockerDaemonBuilder(logger *log.Logger) (*DockerDaemonBuilder, error) {
+	cli, err := client.NewEnvClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize docker client: %w", err)
+	}
+
+	return &DockerDaemonBuilder{
+		client: cli,
+		logger: logger,
+	}, nil
+}
+
+// Build archives files and dockerfilePath into a tar build context and asks
+// the docker daemon to build it. It always returns a nil v1.Image: the
+// docker daemon builds and stores the image itself, and the build API
+// response doesn't hand back a go-containerregistry image to report on.
+func (b *DockerDaemonBuilder) Build(ctx context.Context, files []string, dockerfilePath string, tags []string) (v1.Image, error) {
+	tarFile := path.Join(os.Getenv("PWD"), "context.tar")
+
+	archiveFiles := append(append([]string{}, files...), dockerfilePath)
+	if err := createArchive(tarFile, archiveFiles); err != nil {
+		return nil, err
+	}
+
+	buildContext, err := os.Open(tarFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tarball %s: %w", tarFile, err)
+	}
+	defer buildContext.Close()
+
+	options := types.ImageBuildOptions{
+		Dockerfile: dockerfilePath,
+		Tags:       tags,
+	}
+	resp, err := b.client.ImageBuild(ctx, buildContext, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	buf := new(bytes.Buffer)
+	buf.ReadFrom(resp.Body)
+	b.logger.Print(buf.String())
+
+	return nil, nil
+}
+
+// createArchive writes filePaths into a tar archive at name.
+func createArchive(name string, filePaths []string) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create tar archive %q: %w", name, err)
+	}
+	defer file.Close()
+
+	tw := tar.NewWriter(file)
+	defer tw.Close()
+
+	for _, filePath := range filePaths {
+		fileInfo, err := os.Stat(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to read file info %q: %w", filePath, err)
+		}
+
+		bs, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to read file %q: %w", filePath, err)
+		}
+
+		hdr := &tar.Header{
+			Name: filePath,
+			Mode: 0600,
+			Size: fileInfo.Size(),
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("failed to write tar header: %w", err)
+		}
+
+		if _, err := tw.Write(bs); err != nil {
+			return fmt.Errorf("failed to write tar body: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// defaultPlatform is used for the synthesized image config when no platform
+// is requested explicitly.
+var defaultPlatform = v1.Platform{OS: "linux", Architecture: "amd64"}
+
+// GgcrBuilder assembles images directly from the generated file pool using
+// go-containerregistry, without requiring a running docker daemon: each file
+// is tarred into its own layer, appended to empty.Image, and given a
+// synthesized config.
+type GgcrBuilder struct {
+	logger   *log.Logger
+	platform v1.Platform
+}
+
+// GgcrBuilderOpt configures a GgcrBuilder constructed by NewGgcrBuilder.
+type GgcrBuilderOpt func(b *GgcrBuilder)
+
+// WithPlatform sets the OS/Architecture/Variant recorded in the config of
+// images produced by this builder. Defaults to linux/amd64.
+func WithPlatform(p v1.Platform) GgcrBuilderOpt {
+	return func(b *GgcrBuilder) {
+		b.platform = p
+	}
+}
+
+// NewGgcrBuilder constructs a GgcrBuilder.
+func NewGgcrBuilder(logger *log.Logger, opts ...GgcrBuilderOpt) *GgcrBuilder {
+	b := &GgcrBuilder{
+		logger:   logger,
+		platform: defaultPlatform,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+// Build tars each file into its own layer, appends them to a freshly
+// synthesized image, and loads the result into the local docker daemon
+// under tags, returning the assembled image so callers can report its
+// digest. dockerfilePath is accepted to satisfy the Builder interface but
+// is unused: GgcrBuilder synthesizes its own config rather than
+// interpreting a Dockerfile.
+func (b *GgcrBuilder) Build(ctx context.Context, files []string, dockerfilePath string, tags []string) (v1.Image, error) {
+	img, err := b.buildImage(files)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := NewDaemonOutput(b.logger).WriteAll(ctx, []NamedImage{{Image: img, Tags: tags}}); err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
+
+func (b *GgcrBuilder) buildImage(files []string) (v1.Image, error) {
+	return assembleImage(files, b.platform)
+}
+
+// assembleImage tars each file into its own layer, appends them to a
+// freshly synthesized image, and sets a minimal config describing
+// platform. Files sharing a path (as produced by a deduping
+// RandomImageFactory) resolve to the same v1.Layer, so the resulting image
+// reuses that layer's digest rather than rebuilding it.
+func assembleImage(files []string, platform v1.Platform) (v1.Image, error) {
+	layerCache := map[string]v1.Layer{}
+	layers := make([]v1.Layer, 0, len(files))
+
+	for _, filePath := range files {
+		layer, ok := layerCache[filePath]
+		if !ok {
+			filePath := filePath
+			var err error
+			layer, err = tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+				return tarSingleFile(filePath)
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to construct layer for %q: %w", filePath, err)
+			}
+			layerCache[filePath] = layer
+		}
+		layers = append(layers, layer)
+	}
+
+	img, err := mutate.AppendLayers(empty.Image, layers...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to append layers: %w", err)
+	}
+
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	cfg = cfg.DeepCopy()
+	cfg.Created = v1.Time{Time: sourceDateEpoch()}
+	cfg.Config.Env = []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"}
+	cfg.Config.Entrypoint = []string{"/bin/true"}
+	cfg.OS = platform.OS
+	cfg.Architecture = platform.Architecture
+	cfg.Variant = platform.Variant
+
+	// mutate.AppendLayers stamps each history entry with time.Now(); pin
+	// them to the same deterministic timestamp as cfg.Created so the config
+	// digest only depends on layer content.
+	for i := range cfg.History {
+		cfg.History[i].Created = v1.Time{Time: sourceDateEpoch()}
+	}
+
+	img, err = mutate.ConfigFile(img, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set config file: %w", err)
+	}
+
+	return img, nil
+}
+
+// tarSingleFile archives a single file into an in-memory tar stream, preserving
+// permissions and, where the platform supports it, extended attributes that
+// a Dockerfile `ADD` would otherwise lose. Each generated file becomes its
+// own layer so that identical file content (as produced by a deduping
+// RandomImageFactory) produces an identical, cacheable layer digest.
+func tarSingleFile(filePath string) (io.ReadCloser, error) {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file info %q: %w", filePath, err)
+	}
+
+	hdr, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to build tar header for %q: %w", filePath, err)
+	}
+	// Use the basename rather than filePath itself: the parent directory is
+	// an on-disk storage detail (seed, image index, dedup caching) that
+	// shouldn't leak into the tar entry and therefore the layer digest,
+	// which should depend only on file content and name.
+	hdr.Name = filepath.Base(filePath)
+
+	// Pin every field that would otherwise vary between otherwise-identical
+	// runs (mtime from the filesystem, uid/gid from the process), so the
+	// resulting layer digest depends only on file content and name.
+	hdr.ModTime = sourceDateEpoch()
+	hdr.AccessTime = time.Time{}
+	hdr.ChangeTime = time.Time{}
+	hdr.Uid = 0
+	hdr.Gid = 0
+	hdr.Uname = ""
+	hdr.Gname = ""
+
+	if err := addXattrs(hdr, filePath); err != nil {
+		return nil, fmt.Errorf("failed to read xattrs for %q: %w", filePath, err)
+	}
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return nil, fmt.Errorf("failed to write tar header: %w", err)
+	}
+
+	bs, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %q: %w", filePath, err)
+	}
+
+	if _, err := tw.Write(bs); err != nil {
+		return nil, fmt.Errorf("failed to write tar body: %w", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close tar writer: %w", err)
+	}
+
+	return ioutil.NopCloser(buf), nil
+}
@@ -0,0 +1,266 @@
+package image
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/daemon"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"golang.org/x/sync/errgroup"
+)
+
+// remoteWriteJobs bounds how many layers remote.Write uploads concurrently
+// for a single image.
+const remoteWriteJobs = 4
+
+// NamedImage pairs an assembled image with the tags it should be published
+// under.
+type NamedImage struct {
+	Image v1.Image
+	Tags  []string
+}
+
+// Output publishes a batch of already-assembled images to a destination: a
+// local docker daemon, an OCI image layout directory, a docker-archive
+// tarball, or a remote registry.
+type Output interface {
+	WriteAll(ctx context.Context, images []NamedImage) error
+}
+
+// NamedIndex pairs an assembled image index (a multi-platform manifest
+// list) with the tags it should be published under.
+type NamedIndex struct {
+	Index v1.ImageIndex
+	Tags  []string
+}
+
+// IndexOutput publishes a batch of already-assembled image indexes. Not
+// every Output supports this: the classic docker-archive format has no
+// notion of a manifest list, so ArchiveOutput does not implement it.
+type IndexOutput interface {
+	WriteIndexAll(ctx context.Context, indexes []NamedIndex) error
+}
+
+// DaemonOutput loads images into a local docker daemon, tagging each one
+// with its NamedImage.Tags.
+type DaemonOutput struct {
+	logger *log.Logger
+}
+
+// NewDaemonOutput constructs a DaemonOutput.
+func NewDaemonOutput(logger *log.Logger) *DaemonOutput {
+	return &DaemonOutput{logger: logger}
+}
+
+// WriteAll loads each image into the local docker daemon under its tags.
+func (o *DaemonOutput) WriteAll(ctx context.Context, images []NamedImage) error {
+	for _, ni := range images {
+		for _, t := range ni.Tags {
+			tag, err := name.NewTag(t)
+			if err != nil {
+				return fmt.Errorf("failed to parse tag %q: %w", t, err)
+			}
+
+			if _, err := daemon.Write(tag, ni.Image); err != nil {
+				return fmt.Errorf("failed to load image %q into docker daemon: %w", t, err)
+			}
+			o.logger.Printf("loaded image %s", tag)
+		}
+	}
+
+	return nil
+}
+
+// RegistryOutput publishes images straight to a remote registry using
+// go-containerregistry's remote.Write, resolving credentials via
+// authn.DefaultKeychain (docker config, ECR/GCR/ACR helpers, etc).
+type RegistryOutput struct {
+	logger   *log.Logger
+	insecure bool
+}
+
+// NewRegistryOutput constructs a RegistryOutput.
+func NewRegistryOutput(logger *log.Logger, insecure bool) *RegistryOutput {
+	return &RegistryOutput{logger: logger, insecure: insecure}
+}
+
+// WriteAll pushes every image to each of its tags concurrently, streaming
+// layers straight to the registry.
+func (o *RegistryOutput) WriteAll(ctx context.Context, images []NamedImage) error {
+	g, ctx := errgroup.WithContext(ctx)
+
+	var nameOpts []name.Option
+	writeOpts := []remote.Option{
+		remote.WithContext(ctx),
+		remote.WithAuthFromKeychain(authn.DefaultKeychain),
+		remote.WithJobs(remoteWriteJobs),
+	}
+	if o.insecure {
+		nameOpts = append(nameOpts, name.Insecure)
+		writeOpts = append(writeOpts, remote.WithTransport(&http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // nolint:gosec
+		}))
+	}
+
+	for _, ni := range images {
+		ni := ni
+		for _, t := range ni.Tags {
+			t := t
+			ref, err := name.ParseReference(t, nameOpts...)
+			if err != nil {
+				return fmt.Errorf("failed to parse tag %q: %w", t, err)
+			}
+
+			g.Go(func() error {
+				if err := remote.Write(ref, ni.Image, writeOpts...); err != nil {
+					return fmt.Errorf("failed to push image %q: %w", t, err)
+				}
+				o.logger.Printf("pushed image %s", ref)
+				return nil
+			})
+		}
+	}
+
+	return g.Wait()
+}
+
+// WriteIndexAll pushes every manifest referenced by each index before
+// pushing the index itself, to retain referential integrity.
+func (o *RegistryOutput) WriteIndexAll(ctx context.Context, indexes []NamedIndex) error {
+	g, ctx := errgroup.WithContext(ctx)
+
+	var nameOpts []name.Option
+	writeOpts := []remote.Option{
+		remote.WithContext(ctx),
+		remote.WithAuthFromKeychain(authn.DefaultKeychain),
+		remote.WithJobs(remoteWriteJobs),
+	}
+	if o.insecure {
+		nameOpts = append(nameOpts, name.Insecure)
+		writeOpts = append(writeOpts, remote.WithTransport(&http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // nolint:gosec
+		}))
+	}
+
+	for _, ni := range indexes {
+		ni := ni
+		for _, t := range ni.Tags {
+			t := t
+			ref, err := name.ParseReference(t, nameOpts...)
+			if err != nil {
+				return fmt.Errorf("failed to parse tag %q: %w", t, err)
+			}
+
+			g.Go(func() error {
+				if err := remote.WriteIndex(ref, ni.Index, writeOpts...); err != nil {
+					return fmt.Errorf("failed to push image index %q: %w", t, err)
+				}
+				o.logger.Printf("pushed image index %s", ref)
+				return nil
+			})
+		}
+	}
+
+	return g.Wait()
+}
+
+// LayoutOutput writes images into an OCI image layout directory, suitable
+// for `skopeo copy oci:...`.
+type LayoutOutput struct {
+	path string
+}
+
+// NewLayoutOutput constructs a LayoutOutput rooted at path.
+func NewLayoutOutput(path string) *LayoutOutput {
+	return &LayoutOutput{path: path}
+}
+
+// WriteAll initializes an OCI image layout at o.path and appends every
+// image to it, annotating each with its first tag as its ref name.
+func (o *LayoutOutput) WriteAll(ctx context.Context, images []NamedImage) error {
+	p, err := layout.Write(o.path, empty.Index)
+	if err != nil {
+		return fmt.Errorf("failed to initialize OCI image layout %q: %w", o.path, err)
+	}
+
+	for _, ni := range images {
+		var opts []layout.Option
+		if len(ni.Tags) > 0 {
+			opts = append(opts, layout.WithAnnotations(map[string]string{
+				"org.opencontainers.image.ref.name": ni.Tags[0],
+			}))
+		}
+
+		if err := p.AppendImage(ni.Image, opts...); err != nil {
+			return fmt.Errorf("failed to append image to layout %q: %w", o.path, err)
+		}
+	}
+
+	return nil
+}
+
+// WriteIndexAll initializes an OCI image layout at o.path and appends every
+// index to it, annotating each with its first tag as its ref name.
+func (o *LayoutOutput) WriteIndexAll(ctx context.Context, indexes []NamedIndex) error {
+	p, err := layout.Write(o.path, empty.Index)
+	if err != nil {
+		return fmt.Errorf("failed to initialize OCI image layout %q: %w", o.path, err)
+	}
+
+	for _, ni := range indexes {
+		var opts []layout.Option
+		if len(ni.Tags) > 0 {
+			opts = append(opts, layout.WithAnnotations(map[string]string{
+				"org.opencontainers.image.ref.name": ni.Tags[0],
+			}))
+		}
+
+		if err := p.AppendIndex(ni.Index, opts...); err != nil {
+			return fmt.Errorf("failed to append index to layout %q: %w", o.path, err)
+		}
+	}
+
+	return nil
+}
+
+// ArchiveOutput writes images into a single docker-archive tarball holding
+// one manifest.json entry per image, each with its own RepoTags.
+type ArchiveOutput struct {
+	path string
+}
+
+// NewArchiveOutput constructs an ArchiveOutput that writes to path.
+func NewArchiveOutput(path string) *ArchiveOutput {
+	return &ArchiveOutput{path: path}
+}
+
+// WriteAll writes every image, under all of its tags, into a single
+// docker-archive tarball at o.path.
+func (o *ArchiveOutput) WriteAll(ctx context.Context, images []NamedImage) error {
+	tagToImage := map[name.Tag]v1.Image{}
+
+	for _, ni := range images {
+		for _, t := range ni.Tags {
+			tag, err := name.NewTag(t)
+			if err != nil {
+				return fmt.Errorf("failed to parse tag %q: %w", t, err)
+			}
+			tagToImage[tag] = ni.Image
+		}
+	}
+
+	if err := tarball.MultiWriteToFile(o.path, tagToImage); err != nil {
+		return fmt.Errorf("failed to write docker-archive %q: %w", o.path, err)
+	}
+
+	return nil
+}
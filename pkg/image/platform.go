@@ -0,0 +1,36 @@
+package image
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// ParsePlatform parses a platform string of the form "os/arch" or
+// "os/arch/variant", as accepted by the --platform flag.
+func ParsePlatform(s string) (v1.Platform, error) {
+	parts := strings.Split(s, "/")
+	switch len(parts) {
+	case 2:
+		return v1.Platform{OS: parts[0], Architecture: parts[1]}, nil
+	case 3:
+		return v1.Platform{OS: parts[0], Architecture: parts[1], Variant: parts[2]}, nil
+	default:
+		return v1.Platform{}, fmt.Errorf("invalid platform %q, expected os/arch or os/arch/variant", s)
+	}
+}
+
+// ParsePlatforms parses a comma-separated list of platform strings, as
+// accepted by the --platforms flag.
+func ParsePlatforms(ss []string) ([]v1.Platform, error) {
+	platforms := make([]v1.Platform, 0, len(ss))
+	for _, s := range ss {
+		p, err := ParsePlatform(s)
+		if err != nil {
+			return nil, err
+		}
+		platforms = append(platforms, p)
+	}
+	return platforms, nil
+}
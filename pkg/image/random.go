@@ -1,40 +1,33 @@
 package image
 
 import (
-	"archive/tar"
-	"bytes"
 	"context"
 	"fmt"
+	"hash/fnv"
 	"io/ioutil"
 	"log"
 	"math/rand"
 	"os"
 	"path"
+	"sort"
 
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/client"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
 )
 
-const (
-	// letter* variables are used in RandomImageFactory.randBytes to efficiently
-	// produce a slice of random bytes for use in generating a random pool of
-	// files to be added to randomly-generated docker images.
-	letterBytes   = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
-	letterIdxBits = 6                    // 6 bits to represent a letter index
-	letterIdxMask = 1<<letterIdxBits - 1 // All 1-bits, as many as letterIdxBits
-	letterIdxMax  = 63 / letterIdxBits   // # of letter indices fitting in 63 bits
-
-	baseImageDir = "./generated-files"
-)
+const baseImageDir = "./generated-files"
 
 // RandomImageFactory generates random images with the specified layer size and
 // count parameters.
 type RandomImageFactory struct {
+	seed              int64
 	imageDir          string
 	src               *rand.Rand
-	dockerClient      *client.Client
-	allGeneratedFiles []string
+	builder           Builder
 	logger            *log.Logger
+	contentProfile    LayerContentProfile
+	targetSizeContent map[string][]byte
 }
 
 type RandomImageFactoryOpt func(f *RandomImageFactory)
@@ -42,8 +35,10 @@ type RandomImageFactoryOpt func(f *RandomImageFactory)
 func NewRandomImageFactory(seed int64, opts ...RandomImageFactoryOpt) RandomImageFactory {
 
 	f := RandomImageFactory{
-		imageDir: path.Join(baseImageDir, fmt.Sprintf("%d", seed)),
-		src:      rand.New(rand.NewSource(seed)),
+		seed:           seed,
+		imageDir:       path.Join(baseImageDir, fmt.Sprintf("%d", seed)),
+		src:            rand.New(rand.NewSource(seed)),
+		contentProfile: DefaultLayerContentProfile,
 	}
 
 	for _, opt := range opts {
@@ -59,182 +54,382 @@ func WithLogger(l *log.Logger) RandomImageFactoryOpt {
 	}
 }
 
+// WithBuilder configures the Builder used to turn generated files into a
+// published image. If unset, GenerateImage defaults to a
+// DockerDaemonBuilder.
+func WithBuilder(b Builder) RandomImageFactoryOpt {
+	return func(f *RandomImageFactory) {
+		f.builder = b
+	}
+}
+
+// WithContentProfile configures how generated file content fills each
+// layer. If unset, defaults to DefaultLayerContentProfile.
+func WithContentProfile(p LayerContentProfile) RandomImageFactoryOpt {
+	return func(f *RandomImageFactory) {
+		f.contentProfile = p
+	}
+}
+
+// WithImageDir overrides the directory generated file pools are written
+// under, which otherwise defaults to a path derived from seed. verify uses
+// this to point each of its runs at its own throwaway directory so that
+// generateFilePool's on-disk cache can't mask a run that never actually
+// regenerated its content.
+func WithImageDir(dir string) RandomImageFactoryOpt {
+	return func(f *RandomImageFactory) {
+		f.imageDir = dir
+	}
+}
+
 // GenerateImage generates unique files filled with random bytes then uses
 // those files to build a docker image with layers filled using the
-// randomly-generated files according to the random layer count and layer size
-// parameters specified in RandomImageFactory
-func (f *RandomImageFactory) GenerateImage(layerSizeKB, layerCount uint, tags []string) error {
+// randomly-generated files according to the random layer count and layer
+// size parameters specified in RandomImageFactory. When count > 1, index
+// disambiguates this call's file pool into its own subdirectory, exactly as
+// GenerateImages does per image, so that calling it count times against a
+// factory with the same seed produces count distinct images instead of
+// reusing the one cached file pool for all of them. It returns a
+// DigestReport when f.builder assembled a v1.Image in-process; builders that
+// hand the build off to an external tool with no image handle of their own
+// (DockerDaemonBuilder) leave the returned report zero-valued.
+func (f *RandomImageFactory) GenerateImage(index, count, layerSizeKB, layerCount uint, tags []string) (DigestReport, error) {
 	if f.logger == nil {
 		f.logger = log.New(ioutil.Discard, "", log.LstdFlags)
 	}
-	err := f.generateRandomFilePool(layerSizeKB, layerCount)
-	if err != nil {
-		return err
+	if f.builder == nil {
+		builder, err := NewDockerDaemonBuilder(f.logger)
+		if err != nil {
+			return DigestReport{}, err
+		}
+		f.builder = builder
 	}
 
-	f.shuffleGeneratedFilePaths()
-
-	dockerfilePath, err := f.generateDockerfile(f.allGeneratedFiles)
-	if err != nil {
-		return err
+	dir := f.imageDir
+	if count > 1 {
+		dir = path.Join(f.imageDir, fmt.Sprintf("image-%d", index))
 	}
 
-	tarFile := path.Join(os.Getenv("PWD"), "context.tar")
-	files := append(f.allGeneratedFiles, dockerfilePath)
-	err = f.createArchive(tarFile, files)
+	files, err := f.generateFilePool(dir, layerSizeKB, layerCount)
 	if err != nil {
-		return err
+		return DigestReport{}, err
 	}
+	sort.Strings(files)
 
-	cli, err := f.getDockerClient()
+	dockerfilePath, err := f.generateDockerfile(files)
 	if err != nil {
-		return err
+		return DigestReport{}, err
 	}
 
-	buildContext, err := os.Open(tarFile)
+	img, err := f.builder.Build(context.Background(), files, dockerfilePath, tags)
 	if err != nil {
-		return fmt.Errorf("failed to open tarball %s: %w", tarFile, err)
+		return DigestReport{}, err
 	}
-
-	options := types.ImageBuildOptions{
-		Dockerfile: dockerfilePath,
-		Tags:       tags,
-	}
-	resp, err := cli.ImageBuild(context.Background(), buildContext, options)
-	if err != nil {
-		return fmt.Errorf("failed to build image: %w", err)
+	if img == nil {
+		return DigestReport{}, nil
 	}
 
-	buf := new(bytes.Buffer)
-	buf.ReadFrom(resp.Body)
-	f.logger.Print(buf.String())
-
-	return nil
+	return NewDigestReport(tags, img)
 }
 
-func (f *RandomImageFactory) createArchive(name string, filePaths []string) error {
-	file, err := os.Create(name)
-	if err != nil {
-		return fmt.Errorf("failed to create tar archive %q: %w", name, err)
+// GenerateImages generates count distinct random images — each with its own
+// random file pool — assembles them with go-containerregistry, and writes
+// them all to output in a single call. This is much faster than calling
+// GenerateImage count times when output supports holding many images at
+// once, such as ArchiveOutput or LayoutOutput. Tags are suffixed per-image
+// via IndexTags so that images sharing the same base tags remain
+// distinguishable. It returns a DigestReport per image, in the same order.
+func (f *RandomImageFactory) GenerateImages(count, layerSizeKB, layerCount uint, tags []string, platform v1.Platform, output Output) ([]DigestReport, error) {
+	if f.logger == nil {
+		f.logger = log.New(ioutil.Discard, "", log.LstdFlags)
 	}
 
-	tw := tar.NewWriter(file)
-	defer func() {
-		tw.Close()
-	}()
+	named := make([]NamedImage, 0, count)
+	reports := make([]DigestReport, 0, count)
+	for i := uint(0); i < count; i++ {
+		dir := path.Join(f.imageDir, fmt.Sprintf("image-%d", i))
+		imageTags := IndexTags(tags, i, count)
 
-	for _, filePath := range filePaths {
-		fileInfo, err := os.Stat(filePath)
+		img, err := f.buildImageFromDir(dir, layerSizeKB, layerCount, platform)
 		if err != nil {
-			return fmt.Errorf("failed to read file info %q: %w", filePath, err)
+			return nil, err
 		}
 
-		bs, err := ioutil.ReadFile(filePath)
+		report, err := NewDigestReport(imageTags, img)
 		if err != nil {
-			return fmt.Errorf("failed to read file %q: %w", filePath, err)
+			return nil, err
 		}
 
-		hdr := &tar.Header{
-			Name: filePath,
-			Mode: 0600,
-			Size: fileInfo.Size(),
-		}
+		named = append(named, NamedImage{Image: img, Tags: imageTags})
+		reports = append(reports, report)
+	}
 
-		err = tw.WriteHeader(hdr)
-		if err != nil {
-			return fmt.Errorf("failed to write tar header: %w", err)
-		}
+	if err := output.WriteAll(context.Background(), named); err != nil {
+		return nil, err
+	}
+
+	return reports, nil
+}
+
+// GenerateIndex builds one image per entry in platforms — each with its own
+// random file pool, seeded deterministically from (seed, platform) so runs
+// are reproducible — combines them into a single v1.ImageIndex, and writes
+// the result to output under tags. It returns a DigestReport describing the
+// index and the manifest digest of each platform image it contains.
+func (f *RandomImageFactory) GenerateIndex(platforms []v1.Platform, layerSizeKB, layerCount uint, tags []string, output IndexOutput) (DigestReport, error) {
+	if f.logger == nil {
+		f.logger = log.New(ioutil.Discard, "", log.LstdFlags)
+	}
+
+	addenda := make([]mutate.IndexAddendum, 0, len(platforms))
+	for _, p := range platforms {
+		platformFactory := NewRandomImageFactory(platformSeed(f.seed, p), WithLogger(f.logger), WithContentProfile(f.contentProfile))
 
-		_, err = tw.Write(bs)
+		dir := path.Join(platformFactory.imageDir, "index", platformDirName(p))
+
+		p := p
+		img, err := platformFactory.buildImageFromDir(dir, layerSizeKB, layerCount, p)
 		if err != nil {
-			return fmt.Errorf("failed to write tar body: %w", err)
+			return DigestReport{}, fmt.Errorf("failed to assemble image for platform %s: %w", platformDirName(p), err)
 		}
+
+		addenda = append(addenda, mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: &p},
+		})
 	}
 
-	return nil
+	idx := mutate.AppendManifests(empty.Index, addenda...)
+
+	report, err := NewIndexDigestReport(tags, idx)
+	if err != nil {
+		return DigestReport{}, err
+	}
+
+	if err := output.WriteIndexAll(context.Background(), []NamedIndex{{Index: idx, Tags: tags}}); err != nil {
+		return DigestReport{}, err
+	}
+
+	return report, nil
+}
+
+// BuildImage generates this factory's random file pool and assembles it
+// into a v1.Image without writing to any output sink. It's exported
+// directly so verify can rebuild an image twice from the same seed and
+// compare digests.
+func (f *RandomImageFactory) BuildImage(layerSizeKB, layerCount uint, platform v1.Platform) (v1.Image, error) {
+	if f.logger == nil {
+		f.logger = log.New(ioutil.Discard, "", log.LstdFlags)
+	}
+
+	return f.buildImageFromDir(f.imageDir, layerSizeKB, layerCount, platform)
+}
+
+// buildImageFromDir generates a random file pool under dir, sorted into
+// deterministic order, and assembles it into a v1.Image for platform.
+func (f *RandomImageFactory) buildImageFromDir(dir string, layerSizeKB, layerCount uint, platform v1.Platform) (v1.Image, error) {
+	files, err := f.generateFilePool(dir, layerSizeKB, layerCount)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	return assembleImage(files, platform)
+}
+
+// platformSeed derives a seed for platform from seed, so that regenerating
+// the same (seed, platform) pair always produces the same layer content.
+func platformSeed(seed int64, p v1.Platform) int64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d/%s", seed, platformDirName(p))
+	return int64(h.Sum64())
 }
 
-func (f *RandomImageFactory) shuffleGeneratedFilePaths() {
-	for i := range f.allGeneratedFiles {
-		j := f.src.Intn(i + 1)
-		f.allGeneratedFiles[i], f.allGeneratedFiles[j] = f.allGeneratedFiles[j], f.allGeneratedFiles[i]
+// platformDirName turns a platform into a filesystem- and digest-stable
+// string, e.g. "linux-arm64-v8".
+func platformDirName(p v1.Platform) string {
+	if p.Variant == "" {
+		return fmt.Sprintf("%s-%s", p.OS, p.Architecture)
 	}
+	return fmt.Sprintf("%s-%s-%s", p.OS, p.Architecture, p.Variant)
 }
 
-func (f *RandomImageFactory) generateRandomFilePool(layerSizeKB, layerCount uint) error {
-	err := os.MkdirAll(f.imageDir, 0700)
+// IndexTags returns tags unchanged when count is 1, or each tag suffixed
+// with "-<index>" otherwise, so that generating more than one image under
+// the same base tags still leaves every image distinguishable.
+func IndexTags(tags []string, index, count uint) []string {
+	if count <= 1 {
+		return tags
+	}
+
+	out := make([]string, len(tags))
+	for i, t := range tags {
+		out[i] = fmt.Sprintf("%s-%d", t, index)
+	}
+	return out
+}
+
+// generateFilePool writes layerCount files of layerSizeKB each into dir,
+// reusing any that already exist, and returns their paths. Content is
+// produced according to f.contentProfile; when the profile's DedupRatio
+// rolls true for a given slot, that slot reuses an earlier file's path
+// verbatim instead of generating new content, so the corresponding layer
+// ends up with an identical digest.
+func (f *RandomImageFactory) generateFilePool(dir string, layerSizeKB, layerCount uint) ([]string, error) {
+	err := os.MkdirAll(dir, 0700)
 	if err != nil {
-		return fmt.Errorf("failed creating directory %q: %w", f.imageDir, err)
+		return nil, fmt.Errorf("failed creating directory %q: %w", dir, err)
 	}
 
+	var files []string
 	for i := uint(0); i < layerCount; i++ {
-		filePath := path.Join(f.imageDir, fmt.Sprintf("random_%dKB_%d.txt", layerSizeKB, i))
-		f.allGeneratedFiles = append(f.allGeneratedFiles, filePath)
+		if len(files) > 0 && f.src.Float64() < f.contentProfile.DedupRatio {
+			files = append(files, files[f.src.Intn(len(files))])
+			continue
+		}
+
+		filePath := path.Join(dir, fmt.Sprintf("random_%dKB_%d.txt", layerSizeKB, i))
 
 		_, err = os.Stat(filePath)
 		if err == nil {
+			files = append(files, filePath)
 			continue
 		} else if !os.IsNotExist(err) {
-			return fmt.Errorf("error checking if file exists: %w", err)
+			return nil, fmt.Errorf("error checking if file exists: %w", err)
 		}
 
-		err = ioutil.WriteFile(filePath, f.randBytes(1024*int(layerSizeKB)), 0644)
+		content, err := f.generateContent(dir, 1024*int(layerSizeKB))
 		if err != nil {
-			return fmt.Errorf("error writing random bytes to file: %w", err)
+			return nil, fmt.Errorf("failed to generate layer content: %w", err)
 		}
+
+		if err := ioutil.WriteFile(filePath, content, 0644); err != nil {
+			return nil, fmt.Errorf("error writing layer content to file: %w", err)
+		}
+
+		files = append(files, filePath)
 	}
 
-	return nil
+	return files, nil
 }
 
-func (f *RandomImageFactory) generateDockerfile(filePaths []string) (string, error) {
-	filename := "./dockerfile.generated"
-	dockerFile := `FROM scratch
-`
-	for _, path := range filePaths {
-		dockerFile += fmt.Sprintf("ADD %s /opt\n", path)
+// generateContent produces sizeBytes of file content according to
+// f.contentProfile. Target-compressed-size content (including the mixed
+// profile, which defaults its target to half of sizeBytes) is generated
+// once per dir and cached under that key, since the search in
+// generateTargetCompressedSize is the expensive part and every slot within
+// the same image's pool wants the same gzip-compressed size anyway. Keying
+// by dir rather than caching a single factory-wide value keeps that reuse
+// within one image's pool while still giving each image in a --count run
+// its own independent content.
+func (f *RandomImageFactory) generateContent(dir string, sizeBytes int) ([]byte, error) {
+	profile := f.contentProfile
+
+	if profile.TargetCompressedSize > 0 || profile.Entropy == EntropyMixed {
+		if content, ok := f.targetSizeContent[dir]; ok {
+			return content, nil
+		}
+
+		target := int(profile.TargetCompressedSize)
+		if target == 0 {
+			target = sizeBytes / 2
+		}
+
+		content, err := f.generateTargetCompressedSize(sizeBytes, target)
+		if err != nil {
+			return nil, err
+		}
+		if f.targetSizeContent == nil {
+			f.targetSizeContent = make(map[string][]byte)
+		}
+		f.targetSizeContent[dir] = content
+		return content, nil
 	}
 
-	err := ioutil.WriteFile(filename, []byte(dockerFile), 0644)
-	if err != nil {
-		return "", fmt.Errorf("failed to write to %q: %w", filename, err)
+	if profile.Entropy == EntropyLow {
+		return tileBytes(lowEntropyPattern, sizeBytes), nil
 	}
 
-	return filename, nil
+	return f.randFullRangeBytes(sizeBytes), nil
 }
 
-func (f *RandomImageFactory) getDockerClient() (*client.Client, error) {
-	if f.dockerClient != nil {
-		return f.dockerClient, nil
+// generateTargetCompressedSize generates sizeBytes of content shaped as a
+// random prefix followed by a zeroed tail, binary-searching the prefix
+// length until the content's gzip-compressed size lands within
+// compressedSizeTolerance of target. If sizeBytes can't compress up to
+// target (e.g. target exceeds what a fully-random sizeBytes-long prefix
+// ever gzips to), it returns an error instead of silently handing back
+// whatever the search last tried.
+func (f *RandomImageFactory) generateTargetCompressedSize(sizeBytes, target int) ([]byte, error) {
+	if sizeBytes == 0 {
+		return nil, nil
 	}
 
-	cli, err := client.NewEnvClient()
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialized docker client: %w", err)
-	}
+	lo, hi := 0, sizeBytes
+	var best []byte
+	bestDiff := -1
 
-	f.dockerClient = cli
+	for lo < hi {
+		prefixLen := (lo + hi) / 2
 
-	return cli, nil
-}
+		content := make([]byte, sizeBytes)
+		copy(content, f.randFullRangeBytes(prefixLen))
 
-// randBytes is largely copied from the "Mimicing strings.Builder with package
-// unsafe" solution in https://stackoverflow.com/a/31832326, but adjusted to
-// produce a byte array instead of a string
-func (f *RandomImageFactory) randBytes(n int) []byte {
-	b := make([]byte, n)
+		n, err := gzipLen(content)
+		if err != nil {
+			return nil, err
+		}
 
-	for i, cache, remain := n-1, f.src.Int63(), letterIdxMax; i >= 0; {
-		if remain == 0 {
-			cache, remain = f.src.Int63(), letterIdxMax
+		if diff := abs(n - target); bestDiff == -1 || diff < bestDiff {
+			best, bestDiff = content, diff
 		}
-		if idx := int(cache & letterIdxMask); idx < len(letterBytes) {
-			b[i] = letterBytes[idx]
-			i--
+
+		switch {
+		case n > target+compressedSizeTolerance:
+			hi = prefixLen
+		case n < target-compressedSizeTolerance:
+			lo = prefixLen + 1
+		default:
+			return content, nil
 		}
-		cache >>= letterIdxBits
-		remain--
 	}
 
+	if bestDiff > compressedSizeTolerance {
+		return nil, fmt.Errorf("could not reach target compressed size of %d bytes within tolerance %d: closest attempt landed %d bytes away", target, compressedSizeTolerance, bestDiff)
+	}
+
+	return best, nil
+}
+
+// abs returns the absolute value of n.
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// randFullRangeBytes returns n pseudorandom bytes spanning the full byte
+// range, deterministically derived from f's seed. Unlike a restricted
+// alphabet, full-range bytes gzip-compress to near their original size.
+func (f *RandomImageFactory) randFullRangeBytes(n int) []byte {
+	b := make([]byte, n)
+	f.src.Read(b) // *rand.Rand.Read always fills b and returns a nil error
 	return b
 }
+
+func (f *RandomImageFactory) generateDockerfile(filePaths []string) (string, error) {
+	filename := "./dockerfile.generated"
+	dockerFile := `FROM scratch
+`
+	for _, path := range filePaths {
+		dockerFile += fmt.Sprintf("ADD %s /opt\n", path)
+	}
+
+	err := ioutil.WriteFile(filename, []byte(dockerFile), 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to write to %q: %w", filename, err)
+	}
+
+	return filename, nil
+}
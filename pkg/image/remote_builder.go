@@ -0,0 +1,67 @@
+package image
+
+import (
+	"context"
+	"log"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// RemoteBuilder publishes images straight to a remote registry using
+// go-containerregistry, without ever loading the image into a local docker
+// daemon. Images are assembled the same way GgcrBuilder assembles them.
+type RemoteBuilder struct {
+	logger   *log.Logger
+	insecure bool
+	platform v1.Platform
+}
+
+// RemoteBuilderOpt configures a RemoteBuilder constructed by NewRemoteBuilder.
+type RemoteBuilderOpt func(b *RemoteBuilder)
+
+// WithInsecure allows pushing to registries that aren't reachable over
+// verifiable TLS.
+func WithInsecure(insecure bool) RemoteBuilderOpt {
+	return func(b *RemoteBuilder) {
+		b.insecure = insecure
+	}
+}
+
+// WithRemotePlatform sets the platform recorded in the config of images
+// pushed by this builder. Defaults to linux/amd64.
+func WithRemotePlatform(p v1.Platform) RemoteBuilderOpt {
+	return func(b *RemoteBuilder) {
+		b.platform = p
+	}
+}
+
+// NewRemoteBuilder constructs a RemoteBuilder.
+func NewRemoteBuilder(logger *log.Logger, opts ...RemoteBuilderOpt) *RemoteBuilder {
+	b := &RemoteBuilder{
+		logger:   logger,
+		platform: defaultPlatform,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+// Build assembles an image the same way GgcrBuilder does, then pushes it to
+// each of tags, returning the assembled image so callers can report its
+// digest. dockerfilePath is accepted to satisfy the Builder interface but
+// is unused, same as in GgcrBuilder.
+func (b *RemoteBuilder) Build(ctx context.Context, files []string, dockerfilePath string, tags []string) (v1.Image, error) {
+	img, err := assembleImage(files, b.platform)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := NewRegistryOutput(b.logger, b.insecure).WriteAll(ctx, []NamedImage{{Image: img, Tags: tags}}); err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
@@ -0,0 +1,61 @@
+//go:build linux
+// +build linux
+
+package image
+
+import (
+	"archive/tar"
+
+	"golang.org/x/sys/unix"
+)
+
+// addXattrs copies path's extended attributes into hdr's PAX records using
+// the "SCHILY.xattr." prefix GNU tar uses, so they survive in the tar stream
+// instead of being lost the way a Dockerfile `ADD` loses them. Missing or unreadable
+// attributes are skipped rather than treated as fatal.
+func addXattrs(hdr *tar.Header, path string) error {
+	size, err := unix.Listxattr(path, nil)
+	if err != nil || size <= 0 {
+		return nil
+	}
+
+	names := make([]byte, size)
+	if _, err := unix.Listxattr(path, names); err != nil {
+		return nil
+	}
+
+	for _, name := range splitXattrNames(names) {
+		valSize, err := unix.Getxattr(path, name, nil)
+		if err != nil || valSize <= 0 {
+			continue
+		}
+
+		val := make([]byte, valSize)
+		if _, err := unix.Getxattr(path, name, val); err != nil {
+			continue
+		}
+
+		if hdr.PAXRecords == nil {
+			hdr.PAXRecords = map[string]string{}
+		}
+		hdr.PAXRecords["SCHILY.xattr."+name] = string(val)
+	}
+
+	return nil
+}
+
+// splitXattrNames splits the NUL-separated name list returned by
+// unix.Listxattr into individual attribute names.
+func splitXattrNames(b []byte) []string {
+	var names []string
+	start := 0
+	for i, c := range b {
+		if c == 0 {
+			if i > start {
+				names = append(names, string(b[start:i]))
+			}
+			start = i + 1
+		}
+	}
+	return names
+}
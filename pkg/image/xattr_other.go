@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package image
+
+import "archive/tar"
+
+// addXattrs is a no-op on platforms where the extended attribute syscalls
+// used by the linux build are unavailable.
+func addXattrs(hdr *tar.Header, path string) error {
+	return nil
+}